@@ -0,0 +1,33 @@
+package cluster
+
+import "github.com/hdt3213/godis/redis/protocol"
+
+// ExecClusterReshard is the handler for `CLUSTER RESHARD`: after the RBAC check in
+// Authorize it triggers the same slot rebalance a new node join does
+func (cluster *Cluster) ExecClusterReshard(user string) protocol.ErrorReply {
+	if err := cluster.Authorize(user, "CLUSTER RESHARD"); err != nil {
+		return err
+	}
+	go cluster.rebalance(nil)
+	return nil
+}
+
+// ExecClusterForget is the handler for `CLUSTER FORGET nodeOrAlias`: after the RBAC check
+// in Authorize it resolves nodeOrAlias through ResolveAlias, so operators can name the
+// node by its human-readable alias instead of its opaque id, then proposes its eviction
+// from the topology through raft via forgetNode
+func (cluster *Cluster) ExecClusterForget(user string, nodeOrAlias string) protocol.ErrorReply {
+	if err := cluster.Authorize(user, "CLUSTER FORGET"); err != nil {
+		return err
+	}
+	return cluster.forgetNode(cluster.ResolveAlias(nodeOrAlias))
+}
+
+// ExecRaftJoin is the handler for `RAFT JOIN seed`: after the RBAC check in Authorize it
+// runs the normal Join handshake against seed
+func (cluster *Cluster) ExecRaftJoin(user string, seed string) protocol.ErrorReply {
+	if err := cluster.Authorize(user, "RAFT JOIN"); err != nil {
+		return err
+	}
+	return cluster.Join(seed)
+}
@@ -0,0 +1,95 @@
+package cluster
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hdt3213/godis/redis/protocol"
+)
+
+// aliasPattern restricts aliases to a safe, shell/log-friendly charset
+var aliasPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// noAliasPlaceholder is what ClusterNodesInfo prints for a node without an alias;
+// rejected as an alias itself so it stays unambiguous
+const noAliasPlaceholder = "-"
+
+// validAlias reports whether alias is well-formed; it does not check for uniqueness,
+// which depends on the rest of the topology
+func validAlias(alias string) bool {
+	return alias != noAliasPlaceholder && aliasPattern.MatchString(alias)
+}
+
+// SetAlias assigns alias to nodeID, replicating the change through raft so every node
+// agrees on it. It backs the `CLUSTER SET-ALIAS <nodeID> <alias>` command. The check
+// below is only a local fast-fail: two proposers can pass it for the same alias before
+// either's entry commits, so the authoritative check lives at the raft-apply layer in
+// topology.setAlias, which re-validates uniqueness against the committed topology at the
+// moment the entry is actually applied and rejects the losing entry there, the same way
+// the leader serializes any other conflicting write.
+func (cluster *Cluster) SetAlias(nodeID, alias string) protocol.ErrorReply {
+	if !validAlias(alias) {
+		return protocol.MakeErrReply("ERR alias must match [a-zA-Z0-9_.-]{1,64}")
+	}
+	for id, node := range cluster.topology.GetTopology() {
+		if node.Alias == alias && id != nodeID {
+			return protocol.MakeErrReply(fmt.Sprintf("ERR alias %s is already used by node %s", alias, id))
+		}
+	}
+	if err := cluster.topology.setAlias(nodeID, alias); err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	return nil
+}
+
+// splitAliasAddr splits an `alias@address` ref into its two parts; when ref has no '@'
+// it is returned unchanged as the address with an empty alias
+func splitAliasAddr(ref string) (alias, addr string) {
+	if at := strings.IndexByte(ref, '@'); at >= 0 {
+		return ref[:at], ref[at+1:]
+	}
+	return "", ref
+}
+
+// ResolveAlias resolves ref to a node id: ref may be a bare node id, a bare alias, or
+// an `alias@address` pair as accepted by Cluster.Join. When no node carries a matching
+// alias, it falls back to the address part of ref (or ref itself for a bare, unmatched
+// ref) since the caller is expected to dial that directly.
+func (cluster *Cluster) ResolveAlias(ref string) string {
+	alias, addr := splitAliasAddr(ref)
+	if alias == "" {
+		alias = addr
+	}
+	for id, node := range cluster.topology.GetTopology() {
+		if node.Alias == alias {
+			return id
+		}
+	}
+	return addr
+}
+
+// displayName returns a node's alias for log messages when it has one, falling back
+// to its opaque id otherwise, e.g. "import slot %d from %s" reads better as
+// "import slot 42 from cache-node-3" than with a raw UUID
+func (cluster *Cluster) displayName(nodeID string) string {
+	if node, ok := cluster.topology.GetTopology()[nodeID]; ok && node.Alias != "" {
+		return node.Alias
+	}
+	return nodeID
+}
+
+// ClusterNodesInfo renders one line per node in the `CLUSTER NODES` format this package
+// exposes, id/alias/addr ordered the way operators scan for a specific node
+func (cluster *Cluster) ClusterNodesInfo() []string {
+	nodeMap := cluster.topology.GetTopology()
+	lines := make([]string, 0, len(nodeMap))
+	for _, node := range nodeMap {
+		alias := node.Alias
+		if alias == "" {
+			alias = noAliasPlaceholder
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s %d", node.ID, alias, node.Addr, len(node.Slots)))
+	}
+	return lines
+}
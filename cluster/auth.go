@@ -0,0 +1,154 @@
+package cluster
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/hdt3213/godis/config"
+	"github.com/hdt3213/godis/lib/utils"
+	"github.com/hdt3213/godis/redis/client"
+	"github.com/hdt3213/godis/redis/protocol"
+)
+
+// adminCommands lists the operations that require an authenticated caller to hold
+// the "admin" role, checked by Cluster.Authorize
+var adminCommands = map[string]bool{
+	"CLUSTER RESHARD": true,
+	"CLUSTER FORGET":  true,
+	"RAFT JOIN":       true,
+}
+
+// clusterToken returns the shared secret every node must present on `raft join` and on
+// every `gcluster` command. It is read from the cluster-auth-token setting, so every node
+// must be configured with the same value; it is not generated or distributed through raft.
+func clusterToken() string {
+	return config.Properties.ClusterAuthToken
+}
+
+// authenticate sends the shared cluster secret over cli and returns an error reply if
+// the remote node rejects it. Every internal client connection performs this handshake
+// right after Start() before issuing `raft`/`gcluster` commands.
+func authenticate(cli *client.Client) protocol.ErrorReply {
+	token := clusterToken()
+	if token == "" {
+		return nil
+	}
+	ret := cli.Send(utils.ToCmdLine("gcluster", "auth", token))
+	if protocol.IsErrorReply(ret) {
+		return ret.(protocol.ErrorReply)
+	}
+	return nil
+}
+
+// Authorize checks whether user is allowed to run an admin-only command, consulting the
+// RBAC rules kept in the raft-replicated topology so every node enforces the same policy
+func (cluster *Cluster) Authorize(user string, command string) protocol.ErrorReply {
+	if !adminCommands[command] {
+		return nil
+	}
+	if !cluster.topology.hasRole(user, "admin") {
+		return protocol.MakeErrReply(fmt.Sprintf("NOPERM user %s has no permission to run %s", user, command))
+	}
+	return nil
+}
+
+// dial opens a connection to addr, upgrading to mutual TLS when cluster.tls_cert,
+// cluster.tls_key and cluster.tls_ca are all configured; otherwise it behaves like
+// a plain net.Dial("tcp", addr)
+func dial(addr string) (net.Conn, error) {
+	tlsConfig, err := clientTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		return net.Dial("tcp", addr)
+	}
+	return tls.Dial("tcp", addr, tlsConfig)
+}
+
+// ClusterListen opens addr for inbound inter-node connections, upgrading to mutual TLS
+// with serverTLSConfig when cluster.tls_cert/cluster.tls_key/cluster.tls_ca are all
+// configured. The cluster-internal RESP listener (started outside this package) must
+// use this instead of a plain net.Listen, or a TLS-configured dialer will no longer be
+// able to reach it in cleartext.
+func ClusterListen(addr string) (net.Listener, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := serverTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		return lis, nil
+	}
+	return tls.NewListener(lis, tlsConfig), nil
+}
+
+// loadTLSMaterial loads the cluster mTLS cert/key/CA bundle from
+// cluster.tls_cert/cluster.tls_key/cluster.tls_ca, returning (nil, nil, nil) when any
+// of the three is unset, in which case TLS is disabled
+func loadTLSMaterial() (*tls.Certificate, *x509.CertPool, error) {
+	certFile := config.Properties.ClusterTLSCert
+	keyFile := config.Properties.ClusterTLSKey
+	caFile := config.Properties.ClusterTLSCA
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return nil, nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load cluster tls cert/key error: %v", err)
+	}
+	caPool, err := loadCertPool(caFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load cluster tls ca error: %v", err)
+	}
+	return &cert, caPool, nil
+}
+
+// clientTLSConfig builds the TLS config used to dial other nodes: it presents our own
+// certificate (for the peer's mutual-TLS check) and trusts the cluster CA to verify
+// theirs. Returns nil when cluster TLS is not configured.
+func clientTLSConfig() (*tls.Config, error) {
+	cert, caPool, err := loadTLSMaterial()
+	if err != nil || cert == nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		RootCAs:      caPool,
+	}, nil
+}
+
+// serverTLSConfig builds the TLS config used to accept connections from other nodes: it
+// requires and verifies a client certificate signed by the cluster CA, implementing the
+// "mutual" half of mTLS that clientTLSConfig alone can't provide. Returns nil when
+// cluster TLS is not configured.
+func serverTLSConfig() (*tls.Config, error) {
+	cert, caPool, err := loadTLSMaterial()
+	if err != nil || cert == nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		ClientCAs:    caPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, nil
+}
+
+// loadCertPool reads a PEM-encoded CA bundle from path into a *x509.CertPool
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid certificates found in %s", path)
+	}
+	return pool, nil
+}
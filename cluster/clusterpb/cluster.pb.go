@@ -0,0 +1,94 @@
+// Package clusterpb holds the Go types for cluster.proto. These are hand-maintained,
+// not protoc output: they only implement the classic Reset()/String()/ProtoMessage()
+// trio, not protoreflect.ProtoMessage, so they are marshaled with the "json" codec
+// registered in codec.go rather than the grpc default proto codec. Regenerate by hand
+// alongside cluster.proto until this package is wired into the real protoc toolchain.
+package clusterpb
+
+type Node struct {
+	Id    string  `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Addr  string  `protobuf:"bytes,2,opt,name=addr,proto3" json:"addr,omitempty"`
+	Slots []*Slot `protobuf:"bytes,3,rep,name=slots,proto3" json:"slots,omitempty"`
+	Flags uint32  `protobuf:"varint,4,opt,name=flags,proto3" json:"flags,omitempty"`
+	Alias string  `protobuf:"bytes,5,opt,name=alias,proto3" json:"alias,omitempty"`
+}
+
+func (m *Node) Reset()         { *m = Node{} }
+func (m *Node) String() string { return "Node" }
+func (*Node) ProtoMessage()    {}
+
+type Slot struct {
+	Id              uint32   `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	NodeId          string   `protobuf:"bytes,2,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	OldNodeId       string   `protobuf:"bytes,3,opt,name=old_node_id,json=oldNodeId,proto3" json:"old_node_id,omitempty"`
+	Flags           uint32   `protobuf:"varint,4,opt,name=flags,proto3" json:"flags,omitempty"`
+	ReplicaNodeIds  []string `protobuf:"bytes,5,rep,name=replica_node_ids,json=replicaNodeIds,proto3" json:"replica_node_ids,omitempty"`
+	MigrationCursor int64    `protobuf:"varint,6,opt,name=migration_cursor,json=migrationCursor,proto3" json:"migration_cursor,omitempty"`
+}
+
+func (m *Slot) Reset()         { *m = Slot{} }
+func (m *Slot) String() string { return "Slot" }
+func (*Slot) ProtoMessage()    {}
+
+type Topology struct {
+	Nodes []*Node `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+}
+
+func (m *Topology) Reset()         { *m = Topology{} }
+func (m *Topology) String() string { return "Topology" }
+func (*Topology) ProtoMessage()    {}
+
+type JoinRequest struct {
+	Addr string `protobuf:"bytes,1,opt,name=addr,proto3" json:"addr,omitempty"`
+}
+
+func (m *JoinRequest) Reset()         { *m = JoinRequest{} }
+func (m *JoinRequest) String() string { return "JoinRequest" }
+func (*JoinRequest) ProtoMessage()    {}
+
+type JoinResponse struct {
+	SelfNodeId  string    `protobuf:"bytes,1,opt,name=self_node_id,json=selfNodeId,proto3" json:"self_node_id,omitempty"`
+	LeaderId    string    `protobuf:"bytes,2,opt,name=leader_id,json=leaderId,proto3" json:"leader_id,omitempty"`
+	Term        int64     `protobuf:"varint,3,opt,name=term,proto3" json:"term,omitempty"`
+	CommitIndex int64     `protobuf:"varint,4,opt,name=commit_index,json=commitIndex,proto3" json:"commit_index,omitempty"`
+	Topology    *Topology `protobuf:"bytes,5,opt,name=topology,proto3" json:"topology,omitempty"`
+}
+
+func (m *JoinResponse) Reset()         { *m = JoinResponse{} }
+func (m *JoinResponse) String() string { return "JoinResponse" }
+func (*JoinResponse) ProtoMessage()    {}
+
+type MigrateChunk struct {
+	Command [][]byte `protobuf:"bytes,1,rep,name=command,proto3" json:"command,omitempty"`
+	Done    bool     `protobuf:"varint,2,opt,name=done,proto3" json:"done,omitempty"`
+}
+
+func (m *MigrateChunk) Reset()         { *m = MigrateChunk{} }
+func (m *MigrateChunk) String() string { return "MigrateChunk" }
+func (*MigrateChunk) ProtoMessage()    {}
+
+type MigrateRequest struct {
+	SlotId       uint32 `protobuf:"varint,1,opt,name=slot_id,json=slotId,proto3" json:"slot_id,omitempty"`
+	TargetNodeId string `protobuf:"bytes,2,opt,name=target_node_id,json=targetNodeId,proto3" json:"target_node_id,omitempty"`
+	Cursor       int64  `protobuf:"varint,3,opt,name=cursor,proto3" json:"cursor,omitempty"`
+}
+
+func (m *MigrateRequest) Reset()         { *m = MigrateRequest{} }
+func (m *MigrateRequest) String() string { return "MigrateRequest" }
+func (*MigrateRequest) ProtoMessage()    {}
+
+type InstallSnapshotRequest struct {
+	Topology  *Topology `protobuf:"bytes,1,opt,name=topology,proto3" json:"topology,omitempty"`
+	LastIndex int64     `protobuf:"varint,2,opt,name=last_index,json=lastIndex,proto3" json:"last_index,omitempty"`
+	LastTerm  int64     `protobuf:"varint,3,opt,name=last_term,json=lastTerm,proto3" json:"last_term,omitempty"`
+}
+
+func (m *InstallSnapshotRequest) Reset()         { *m = InstallSnapshotRequest{} }
+func (m *InstallSnapshotRequest) String() string { return "InstallSnapshotRequest" }
+func (*InstallSnapshotRequest) ProtoMessage()    {}
+
+type InstallSnapshotResponse struct{}
+
+func (m *InstallSnapshotResponse) Reset()         { *m = InstallSnapshotResponse{} }
+func (m *InstallSnapshotResponse) String() string { return "InstallSnapshotResponse" }
+func (*InstallSnapshotResponse) ProtoMessage()    {}
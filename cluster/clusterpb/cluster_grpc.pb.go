@@ -0,0 +1,169 @@
+// Hand-maintained client/server bindings for the ClusterInternal service described in
+// cluster.proto (see cluster.pb.go for why these aren't real protoc-gen-go-grpc output).
+
+package clusterpb
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+)
+
+// ClusterInternalClient is the client API for ClusterInternal service
+type ClusterInternalClient interface {
+	Join(ctx context.Context, in *JoinRequest, opts ...grpc.CallOption) (*JoinResponse, error)
+	MigrateStream(ctx context.Context, in *MigrateRequest, opts ...grpc.CallOption) (ClusterInternal_MigrateStreamClient, error)
+	InstallSnapshot(ctx context.Context, in *InstallSnapshotRequest, opts ...grpc.CallOption) (*InstallSnapshotResponse, error)
+}
+
+type clusterInternalClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewClusterInternalClient builds a client for the ClusterInternal service
+func NewClusterInternalClient(cc grpc.ClientConnInterface) ClusterInternalClient {
+	return &clusterInternalClient{cc}
+}
+
+func (c *clusterInternalClient) Join(ctx context.Context, in *JoinRequest, opts ...grpc.CallOption) (*JoinResponse, error) {
+	out := new(JoinResponse)
+	err := c.cc.Invoke(ctx, "/clusterpb.ClusterInternal/Join", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterInternalClient) MigrateStream(ctx context.Context, in *MigrateRequest, opts ...grpc.CallOption) (ClusterInternal_MigrateStreamClient, error) {
+	stream, err := c.cc.(*grpc.ClientConn).NewStream(ctx, &_ClusterInternal_serviceDesc.Streams[0], "/clusterpb.ClusterInternal/MigrateStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &clusterInternalMigrateStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (c *clusterInternalClient) InstallSnapshot(ctx context.Context, in *InstallSnapshotRequest, opts ...grpc.CallOption) (*InstallSnapshotResponse, error) {
+	out := new(InstallSnapshotResponse)
+	err := c.cc.Invoke(ctx, "/clusterpb.ClusterInternal/InstallSnapshot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ClusterInternal_MigrateStreamClient is returned by Client.MigrateStream
+type ClusterInternal_MigrateStreamClient interface {
+	Recv() (*MigrateChunk, error)
+	grpc.ClientStream
+}
+
+type clusterInternalMigrateStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *clusterInternalMigrateStreamClient) Recv() (*MigrateChunk, error) {
+	m := new(MigrateChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ClusterInternalServer is the server API for ClusterInternal service
+type ClusterInternalServer interface {
+	Join(context.Context, *JoinRequest) (*JoinResponse, error)
+	MigrateStream(*MigrateRequest, ClusterInternal_MigrateStreamServer) error
+	InstallSnapshot(context.Context, *InstallSnapshotRequest) (*InstallSnapshotResponse, error)
+}
+
+// UnimplementedClusterInternalServer can be embedded in server implementations for
+// forward compatibility with future methods added to the service
+type UnimplementedClusterInternalServer struct{}
+
+func (UnimplementedClusterInternalServer) Join(context.Context, *JoinRequest) (*JoinResponse, error) {
+	return nil, fmt.Errorf("method Join not implemented")
+}
+func (UnimplementedClusterInternalServer) MigrateStream(*MigrateRequest, ClusterInternal_MigrateStreamServer) error {
+	return fmt.Errorf("method MigrateStream not implemented")
+}
+func (UnimplementedClusterInternalServer) InstallSnapshot(context.Context, *InstallSnapshotRequest) (*InstallSnapshotResponse, error) {
+	return nil, fmt.Errorf("method InstallSnapshot not implemented")
+}
+
+// ClusterInternal_MigrateStreamServer is implemented by the server for MigrateStream
+type ClusterInternal_MigrateStreamServer interface {
+	Send(*MigrateChunk) error
+	grpc.ServerStream
+}
+
+type clusterInternalMigrateStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *clusterInternalMigrateStreamServer) Send(m *MigrateChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// RegisterClusterInternalServer registers impl as the handler for the ClusterInternal service
+func RegisterClusterInternalServer(s *grpc.Server, srv ClusterInternalServer) {
+	s.RegisterService(&_ClusterInternal_serviceDesc, srv)
+}
+
+func _ClusterInternal_Join_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JoinRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterInternalServer).Join(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/clusterpb.ClusterInternal/Join"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterInternalServer).Join(ctx, req.(*JoinRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterInternal_MigrateStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(MigrateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ClusterInternalServer).MigrateStream(m, &clusterInternalMigrateStreamServer{stream})
+}
+
+func _ClusterInternal_InstallSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InstallSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterInternalServer).InstallSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/clusterpb.ClusterInternal/InstallSnapshot"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterInternalServer).InstallSnapshot(ctx, req.(*InstallSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _ClusterInternal_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "clusterpb.ClusterInternal",
+	HandlerType: (*ClusterInternalServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Join", Handler: _ClusterInternal_Join_Handler},
+		{MethodName: "InstallSnapshot", Handler: _ClusterInternal_InstallSnapshot_Handler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "MigrateStream", Handler: _ClusterInternal_MigrateStream_Handler, ServerStreams: true},
+	},
+	Metadata: "cluster.proto",
+}
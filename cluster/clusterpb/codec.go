@@ -0,0 +1,31 @@
+package clusterpb
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// jsonCodecName is the content-subtype negotiated for this service. Messages here don't
+// implement protoreflect.ProtoMessage (see cluster.pb.go), so grpc's default proto codec
+// would fail an interface assertion on every call; this codec lets the same types travel
+// over gRPC until real protoc-generated bindings replace them.
+const jsonCodecName = "json"
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return jsonCodecName
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
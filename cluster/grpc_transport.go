@@ -0,0 +1,321 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/hdt3213/godis/cluster/clusterpb"
+	"github.com/hdt3213/godis/config"
+	"github.com/hdt3213/godis/lib/logger"
+	"github.com/hdt3213/godis/lib/utils"
+	"github.com/hdt3213/godis/redis/parser"
+	"github.com/hdt3213/godis/redis/protocol"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// clusterTokenMetadataKey is the gRPC metadata key the shared cluster secret travels
+// under, mirroring the `gcluster auth <token>` handshake the RESP transport uses
+const clusterTokenMetadataKey = "cluster-token"
+
+// grpcEnabled reports whether inter-node cluster traffic should go through gRPC
+// instead of the default RESP transport, controlled by cluster.grpc_enabled
+func grpcEnabled() bool {
+	return config.Properties.ClusterGRPCEnabled
+}
+
+// grpcServer adapts *Cluster to clusterpb.ClusterInternalServer
+type grpcServer struct {
+	clusterpb.UnimplementedClusterInternalServer
+	cluster *Cluster
+}
+
+// StartGRPCServer starts the optional gRPC listener for cluster-internal RPCs on addr.
+// It is only started when cluster.grpc_enabled is true; the RESP listener keeps serving
+// `raft`/`gcluster` commands regardless, for backward compatibility. Every RPC is rejected
+// unless it carries the shared cluster token (see tokenUnaryInterceptor/
+// tokenStreamInterceptor), and the listener itself is wrapped in mTLS when
+// cluster.tls_cert/tls_key/tls_ca are configured
+func StartGRPCServer(cluster *Cluster, addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("grpc listen on %s error: %v", addr, err)
+	}
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(tokenUnaryInterceptor),
+		grpc.StreamInterceptor(tokenStreamInterceptor),
+	}
+	tlsConfig, err := serverTLSConfig()
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	server := grpc.NewServer(opts...)
+	clusterpb.RegisterClusterInternalServer(server, &grpcServer{cluster: cluster})
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			logger.Error(fmt.Sprintf("grpc server stopped: %v", err))
+		}
+	}()
+	logger.Info("cluster grpc listener started at " + addr)
+	return nil
+}
+
+// checkGRPCToken validates the incoming call's cluster-token metadata against
+// clusterToken(), mirroring what authenticate()/the `gcluster auth` RESP handler are
+// expected to enforce on the RESP transport. No-op when no token is configured.
+func checkGRPCToken(ctx context.Context) error {
+	token := clusterToken()
+	if token == "" {
+		return nil
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing cluster token")
+	}
+	values := md.Get(clusterTokenMetadataKey)
+	if len(values) == 0 || values[0] != token {
+		return status.Error(codes.Unauthenticated, "invalid cluster token")
+	}
+	return nil
+}
+
+// tokenUnaryInterceptor rejects unary RPCs that don't carry the shared cluster token
+func tokenUnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := checkGRPCToken(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// tokenStreamInterceptor rejects streaming RPCs that don't carry the shared cluster token
+func tokenStreamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := checkGRPCToken(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// tokenCredentials attaches the shared cluster secret to every outgoing RPC as
+// per-call metadata, the client-side half of tokenUnaryInterceptor/
+// tokenStreamInterceptor's server-side check
+type tokenCredentials struct {
+	token string
+}
+
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{clusterTokenMetadataKey: t.token}, nil
+}
+
+// RequireTransportSecurity is false because the token must still work when cluster TLS
+// is not configured; the token itself, not transport security, is what grpcDial relies
+// on to authenticate a call in that case
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+func (s *grpcServer) Join(ctx context.Context, req *clusterpb.JoinRequest) (*clusterpb.JoinResponse, error) {
+	selfNodeID, leaderID, term, commitIndex, nodes, err := s.cluster.topology.join(req.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return &clusterpb.JoinResponse{
+		SelfNodeId:  selfNodeID,
+		LeaderId:    leaderID,
+		Term:        int64(term),
+		CommitIndex: int64(commitIndex),
+		Topology:    toPBTopology(nodes),
+	}, nil
+}
+
+// MigrateStream bridges the gRPC streaming API onto the existing `gcluster migrate`/
+// `gcluster migrate-resume` RESP command this node already serves, instead of
+// re-implementing slot scanning here: it dials its own RESP listener as a local client,
+// issues the same command importSlot would send over RESP, and re-streams every
+// MultiBulkReply it gets back as a MigrateChunk. This keeps exactly one source of truth
+// for "what does migrating slot N look like" regardless of which transport is in use.
+func (s *grpcServer) MigrateStream(req *clusterpb.MigrateRequest, stream clusterpb.ClusterInternal_MigrateStreamServer) error {
+	conn, err := dial(config.Properties.AnnounceAddress())
+	if err != nil {
+		return fmt.Errorf("bridge to local RESP listener error: %v", err)
+	}
+	defer conn.Close()
+	replies := parser.ParseStream(conn)
+	if token := clusterToken(); token != "" {
+		authCmd := protocol.MakeMultiBulkReply(utils.ToCmdLine("gcluster", "auth", token))
+		if _, err := conn.Write(authCmd.ToBytes()); err != nil {
+			return err
+		}
+		if ret := <-replies; ret == nil || ret.Err != nil || protocol.IsErrorReply(ret.Data) {
+			return fmt.Errorf("auth with local RESP listener error: %v", ret)
+		}
+	}
+	var cmdLine CmdLine
+	if req.Cursor > 0 {
+		cmdLine = utils.ToCmdLine("gcluster", "migrate-resume",
+			strconv.Itoa(int(req.SlotId)), strconv.FormatInt(req.Cursor, 10))
+	} else {
+		cmdLine = utils.ToCmdLine("gcluster", "migrate",
+			strconv.Itoa(int(req.SlotId)), req.TargetNodeId)
+	}
+	if _, err := conn.Write(protocol.MakeMultiBulkReply(cmdLine).ToBytes()); err != nil {
+		return err
+	}
+	for proto := range replies {
+		if proto.Err != nil {
+			return proto.Err
+		}
+		switch reply := proto.Data.(type) {
+		case *protocol.MultiBulkReply:
+			if err := stream.Send(&clusterpb.MigrateChunk{Command: reply.Args}); err != nil {
+				return err
+			}
+		case *protocol.StatusReply:
+			if protocol.IsOKReply(reply) {
+				return stream.Send(&clusterpb.MigrateChunk{Done: true})
+			}
+			return fmt.Errorf("migrate slot %d error: %s", req.SlotId, reply.ToBytes())
+		}
+	}
+	return nil
+}
+
+// InstallSnapshot is the network handler for a leader pushing a compacted topology
+// snapshot to this node, the gRPC counterpart of cluster.installSnapshot's local-disk
+// crash-recovery caller
+func (s *grpcServer) InstallSnapshot(ctx context.Context, req *clusterpb.InstallSnapshotRequest) (*clusterpb.InstallSnapshotResponse, error) {
+	snap := &Snapshot{
+		LastIndex: int(req.LastIndex),
+		LastTerm:  int(req.LastTerm),
+		Nodes:     fromPBTopology(req.Topology),
+	}
+	s.cluster.installSnapshot(snap)
+	return &clusterpb.InstallSnapshotResponse{}, nil
+}
+
+// toPBTopology converts the raft-local node map into its gRPC wire representation
+func toPBTopology(nodes map[string]*Node) *clusterpb.Topology {
+	topology := &clusterpb.Topology{Nodes: make([]*clusterpb.Node, 0, len(nodes))}
+	for _, node := range nodes {
+		pbNode := &clusterpb.Node{
+			Id:    node.ID,
+			Addr:  node.Addr,
+			Flags: node.Flags,
+			Alias: node.Alias,
+			Slots: make([]*clusterpb.Slot, 0, len(node.Slots)),
+		}
+		for _, slot := range node.Slots {
+			pbNode.Slots = append(pbNode.Slots, &clusterpb.Slot{
+				Id:              slot.ID,
+				NodeId:          slot.NodeID,
+				OldNodeId:       slot.OldNodeID,
+				Flags:           slot.Flags,
+				ReplicaNodeIds:  slot.ReplicaNodeIDs,
+				MigrationCursor: int64(slot.MigrationCursor),
+			})
+		}
+		topology.Nodes = append(topology.Nodes, pbNode)
+	}
+	return topology
+}
+
+// fromPBTopology is the inverse of toPBTopology
+func fromPBTopology(topology *clusterpb.Topology) map[string]*Node {
+	nodes := make(map[string]*Node, len(topology.GetNodes()))
+	for _, pbNode := range topology.GetNodes() {
+		node := &Node{ID: pbNode.Id, Addr: pbNode.Addr, Flags: pbNode.Flags, Alias: pbNode.Alias}
+		for _, pbSlot := range pbNode.Slots {
+			node.Slots = append(node.Slots, &Slot{
+				ID:              pbSlot.Id,
+				NodeID:          pbSlot.NodeId,
+				OldNodeID:       pbSlot.OldNodeId,
+				Flags:           pbSlot.Flags,
+				ReplicaNodeIDs:  pbSlot.ReplicaNodeIds,
+				MigrationCursor: int(pbSlot.MigrationCursor),
+			})
+		}
+		nodes[node.ID] = node
+	}
+	return nodes
+}
+
+// pushSnapshotToPeers sends snap to every other known node over gRPC, giving
+// cluster.installSnapshot a real network path in addition to its local-disk
+// crash-recovery caller. Errors are logged and otherwise ignored: a peer that misses a
+// push catches up from its own next periodic snapshot or from replaying the raft log.
+//
+// Not called from maybeCompact yet: see the todo there. A snapshot whose LastIndex/
+// LastTerm aren't real gives a receiving peer no way to tell whether it's actually ahead
+// of what it already has, so installing one over gRPC could lose committed state. This
+// function is kept ready for once that's fixed, rather than wired in now.
+func pushSnapshotToPeers(cluster *Cluster, snap *Snapshot) {
+	if !grpcEnabled() {
+		return
+	}
+	req := &clusterpb.InstallSnapshotRequest{
+		Topology:  toPBTopology(snap.Nodes),
+		LastIndex: int64(snap.LastIndex),
+		LastTerm:  int64(snap.LastTerm),
+	}
+	for _, node := range snap.Nodes {
+		if node.ID == cluster.self {
+			continue
+		}
+		cli, conn, err := grpcDial(node.Addr)
+		if err != nil {
+			logger.Error(fmt.Sprintf("dial %s for snapshot push error: %v", cluster.displayName(node.ID), err))
+			continue
+		}
+		if _, err := cli.InstallSnapshot(context.Background(), req); err != nil {
+			logger.Error(fmt.Sprintf("push snapshot to %s error: %v", cluster.displayName(node.ID), err))
+		}
+		conn.Close()
+	}
+}
+
+// joinViaGRPC performs the `raft join` handshake over gRPC instead of RESP
+func (cluster *Cluster) joinViaGRPC(leaderAddr string) (selfNodeID, leaderID string, term, commitIndex int, nodes map[string]*Node, err error) {
+	cli, conn, err := grpcDial(leaderAddr)
+	if err != nil {
+		return "", "", 0, 0, nil, err
+	}
+	defer conn.Close()
+	resp, err := cli.Join(context.Background(), &clusterpb.JoinRequest{Addr: config.Properties.AnnounceAddress()})
+	if err != nil {
+		return "", "", 0, 0, nil, err
+	}
+	return resp.SelfNodeId, resp.LeaderId, int(resp.Term), int(resp.CommitIndex), fromPBTopology(resp.Topology), nil
+}
+
+// grpcDial opens a client connection to node's gRPC listener, using the same mTLS
+// material as the RESP transport (clientTLSConfig) when it is configured, attaching the
+// shared cluster token to every call so tokenUnaryInterceptor/tokenStreamInterceptor
+// accept it, and always negotiating the "json" codec registered in clusterpb since these
+// message types don't implement protoreflect.ProtoMessage
+func grpcDial(addr string) (clusterpb.ClusterInternalClient, *grpc.ClientConn, error) {
+	opts := []grpc.DialOption{grpc.WithDefaultCallOptions(grpc.CallContentSubtype("json"))}
+	tlsConfig, err := clientTLSConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	if token := clusterToken(); token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials{token: token}))
+	}
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return clusterpb.NewClusterInternalClient(conn), conn, nil
+}
@@ -0,0 +1,95 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hdt3213/godis/database"
+	"github.com/hdt3213/godis/interface/redis"
+	"github.com/hdt3213/godis/redis/client"
+	"github.com/hdt3213/godis/redis/protocol"
+)
+
+// hashTagOf returns the substring of key that getSlot should hash, following Redis
+// Cluster's hash tag rule: if key contains a '{', hash only the text between the first
+// '{' and the next '}' after it. If the tag is empty (`{}`) or unterminated (no closing
+// '}'), fall back to hashing the whole key so multi-key commands can still be pinned to
+// one slot by choosing a real tag.
+func hashTagOf(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 { // no closing brace, or empty tag "{}"
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
+
+// groupKeysBySlot buckets keys by the slot they hash to, used to detect cross-slot
+// multi-key commands before they are executed or forwarded
+func groupKeysBySlot(keys []string) map[uint32][]string {
+	bySlot := make(map[uint32][]string)
+	for _, key := range keys {
+		slotID := getSlot(key)
+		bySlot[slotID] = append(bySlot[slotID], key)
+	}
+	return bySlot
+}
+
+// checkCrossSlot returns a CROSSSLOT error reply when keys do not all hash to the same
+// slot, mirroring Redis Cluster's rejection of multi-key commands that would need to
+// touch more than one node
+func checkCrossSlot(keys []string) protocol.ErrorReply {
+	if len(groupKeysBySlot(keys)) > 1 {
+		return protocol.MakeErrReply("CROSSSLOT Keys in request don't hash to the same slot")
+	}
+	return nil
+}
+
+// routeMultiKeyCommand decides how cluster should handle a multi-key command: if keys
+// don't all land on the same slot it returns a CROSSSLOT error; otherwise it reports
+// whether cluster.self owns that slot (execute locally) or which node to forward to
+func (cluster *Cluster) routeMultiKeyCommand(keys []string) (local bool, node *Node, err protocol.ErrorReply) {
+	if errReply := checkCrossSlot(keys); errReply != nil {
+		return false, nil, errReply
+	}
+	if len(keys) == 0 {
+		return true, nil, nil
+	}
+	slotID := getSlot(keys[0])
+	owner := cluster.topology.PickNode(slotID)
+	return owner.ID == cluster.self, owner, nil
+}
+
+// ExecMultiKeyCommand is the entry point the RESP command dispatcher calls for any
+// multi-key command (MSET, MGET, DEL, ...): it rejects the command with CROSSSLOT if its
+// keys don't share a slot, runs it locally when cluster.self owns that slot, and
+// otherwise forwards it verbatim to the owning node and relays its reply back
+func (cluster *Cluster) ExecMultiKeyCommand(conn redis.Connection, cmdLine CmdLine) redis.Reply {
+	keys, _ := database.GetRelatedKeys(cmdLine)
+	local, node, errReply := cluster.routeMultiKeyCommand(keys)
+	if errReply != nil {
+		return errReply
+	}
+	if local {
+		return cluster.db.Exec(conn, cmdLine)
+	}
+	return cluster.relayToNode(node, cmdLine)
+}
+
+// relayToNode forwards cmdLine verbatim to node and returns its reply, reusing the same
+// client-dial pattern as Join/replicateSlot
+func (cluster *Cluster) relayToNode(node *Node, cmdLine CmdLine) redis.Reply {
+	cli, err := client.MakeClient(node.Addr)
+	if err != nil {
+		return protocol.MakeErrReply(fmt.Sprintf("ERR connect with %s(%s) error: %v", node.ID, node.Addr, err))
+	}
+	cli.Start()
+	defer cli.Close()
+	if authErr := authenticate(cli); authErr != nil {
+		return authErr
+	}
+	return cli.Send(cmdLine)
+}
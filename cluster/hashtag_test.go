@@ -0,0 +1,46 @@
+package cluster
+
+import "testing"
+
+func TestHashTagOf(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"foo", "foo"},
+		{"{user1000}.following", "user1000"},
+		{"foo{}bar", "foo{}bar"},                  // empty tag falls back to the whole key
+		{"foo{bar", "foo{bar"},                    // missing closing brace falls back to the whole key
+		{"{}", "{}"},                               // empty tag, nothing to fall back to but itself
+		{"foo{{bar}}zap", "{bar"},                  // nested '{' is not special, first '{'..next '}' wins
+		{"{user1000}.following{tag}", "user1000"}, // only the first tag matters
+	}
+	for _, tt := range tests {
+		if got := hashTagOf(tt.key); got != tt.want {
+			t.Errorf("hashTagOf(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestGroupKeysBySlot(t *testing.T) {
+	keys := []string{"{tag}a", "{tag}b", "c"}
+	bySlot := groupKeysBySlot(keys)
+	foundTagged := false
+	for _, slotKeys := range bySlot {
+		if len(slotKeys) == 2 {
+			foundTagged = true
+		}
+	}
+	if !foundTagged {
+		t.Errorf("expected keys sharing a hash tag to land in the same slot, got %v", bySlot)
+	}
+}
+
+func TestCheckCrossSlot(t *testing.T) {
+	if err := checkCrossSlot([]string{"{tag}a", "{tag}b"}); err != nil {
+		t.Errorf("keys sharing a hash tag should not be CROSSSLOT, got %v", err)
+	}
+	if err := checkCrossSlot([]string{"a", "b", "c"}); err == nil {
+		t.Errorf("keys hashing to different slots should be rejected as CROSSSLOT")
+	}
+}
@@ -0,0 +1,95 @@
+package cluster
+
+import (
+	"net"
+	"time"
+
+	"github.com/hdt3213/godis/config"
+)
+
+// migrationBatchSize is how many MultiBulkReply commands importSlot accumulates
+// before pipelining them into a single run of db.Exec calls
+const migrationBatchSize = 64
+
+// defaultMaxParallelMigrations bounds concurrent slot migrations when config does not set one
+const defaultMaxParallelMigrations = 4
+
+// maxParallelMigrations returns how many slots rebalance may migrate at the same time
+func maxParallelMigrations() int {
+	if config.Properties.ClusterMaxParallelMigrations <= 0 {
+		return defaultMaxParallelMigrations
+	}
+	return config.Properties.ClusterMaxParallelMigrations
+}
+
+// migrationRateLimitBytesPerSec returns the per-slot migration throttle, 0 meaning unlimited
+func migrationRateLimitBytesPerSec() int {
+	return config.Properties.ClusterMigrationRateLimitBytesPerSec
+}
+
+// rateLimiter is a small token bucket used to throttle per-slot migration traffic by bytes/sec
+type rateLimiter struct {
+	bytesPerSec int
+	budget      int
+	last        time.Time
+}
+
+func newRateLimiter(bytesPerSec int) *rateLimiter {
+	return &rateLimiter{bytesPerSec: bytesPerSec, last: time.Now()}
+}
+
+// wait blocks just long enough to keep the transfer of payload under bytesPerSec
+func (r *rateLimiter) wait(payload []byte) {
+	if r.bytesPerSec <= 0 {
+		return
+	}
+	now := time.Now()
+	r.budget += int(now.Sub(r.last).Seconds() * float64(r.bytesPerSec))
+	if r.budget > r.bytesPerSec {
+		r.budget = r.bytesPerSec
+	}
+	r.last = now
+	r.budget -= len(payload)
+	if r.budget < 0 {
+		time.Sleep(time.Duration(-r.budget) * time.Second / time.Duration(r.bytesPerSec))
+		r.budget = 0
+	}
+}
+
+// dialNode opens a connection to node's inter-node listener, upgrading to mTLS when
+// cluster.tls_cert/cluster.tls_key/cluster.tls_ca are configured
+func dialNode(node *Node) (net.Conn, error) {
+	return dial(node.Addr)
+}
+
+// MigrationStatus reports the progress of one in-flight slot migration, returned by
+// the `CLUSTER MIGRATION-STATUS` command
+type MigrationStatus struct {
+	SlotID      uint32
+	State       string
+	Cursor      int
+	OldNodeID   string
+	NewNodeID   string
+	IsMigrating bool
+}
+
+// GetMigrationStatus returns the migration progress of every slot currently migrating,
+// backing the `CLUSTER MIGRATION-STATUS` command
+func (cluster *Cluster) GetMigrationStatus() []*MigrationStatus {
+	raft := cluster.topology
+	var result []*MigrationStatus
+	for _, slot := range raft.slots {
+		if !slot.IsMigrating() {
+			continue
+		}
+		result = append(result, &MigrationStatus{
+			SlotID:      slot.ID,
+			State:       "importing",
+			Cursor:      slot.MigrationCursor,
+			OldNodeID:   slot.OldNodeID,
+			NewNodeID:   slot.NodeID,
+			IsMigrating: true,
+		})
+	}
+	return result
+}
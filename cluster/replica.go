@@ -0,0 +1,174 @@
+package cluster
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/hdt3213/godis/config"
+	"github.com/hdt3213/godis/lib/logger"
+	"github.com/hdt3213/godis/lib/utils"
+	"github.com/hdt3213/godis/redis/client"
+	"github.com/hdt3213/godis/redis/connection"
+	"github.com/hdt3213/godis/redis/parser"
+	"github.com/hdt3213/godis/redis/protocol"
+)
+
+// defaultReplicationFactor is used when config.Properties does not specify one
+const defaultReplicationFactor = 1
+
+// defaultFailureTimeout is how long a primary may go unheard from, per Node.lastHeard,
+// before the failure detector considers it dead and promotes a replica
+const defaultFailureTimeout = 10 * time.Second
+
+// failureDetectorInterval is how often the leader scans for dead primaries
+const failureDetectorInterval = 2 * time.Second
+
+// failureTimeout returns how long a primary may go unheard from before it is considered
+// dead, from config.ClusterFailureTimeoutMs when set, otherwise defaultFailureTimeout
+func failureTimeout() time.Duration {
+	if config.Properties.ClusterFailureTimeoutMs <= 0 {
+		return defaultFailureTimeout
+	}
+	return time.Duration(config.Properties.ClusterFailureTimeoutMs) * time.Millisecond
+}
+
+// replicationFactor returns how many replica nodes should be kept per slot
+func replicationFactor() int {
+	if config.Properties.ClusterReplicationFactor <= 0 {
+		return defaultReplicationFactor
+	}
+	return config.Properties.ClusterReplicationFactor
+}
+
+// pickReplicaCandidates returns up to n nodes, distinct from the slot's primary and its
+// current replicas, preferring nodes that currently own the fewest slots
+func (cluster *Cluster) pickReplicaCandidates(slot *Slot, n int) []*Node {
+	nodeMap := cluster.topology.GetTopology()
+	nodes := make([]*Node, 0, len(nodeMap))
+	for _, node := range nodeMap {
+		if node.ID == slot.NodeID || slot.HasReplica(node.ID) {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return len(nodes[i].Slots) < len(nodes[j].Slots)
+	})
+	if len(nodes) > n {
+		nodes = nodes[:n]
+	}
+	return nodes
+}
+
+// replicateSlot performs the `gcluster replicate` handshake with node: it sends
+// `gcluster replicate <slotId> <selfId>` and returns once node accepts it. It sends no
+// keyspace snapshot of slot and streams no later writes to node.
+func (cluster *Cluster) replicateSlot(node *Node, slot *Slot) error {
+	replicaCli, err := client.MakeClient(node.Addr)
+	if err != nil {
+		return fmt.Errorf("connect with %s(%s) error: %v", node.ID, node.Addr, err)
+	}
+	replicaCli.Start()
+	if authErr := authenticate(replicaCli); authErr != nil {
+		return fmt.Errorf("replicate slot %d to %s auth error: %v", slot.ID, node.ID, authErr)
+	}
+	ret := replicaCli.Send(utils.ToCmdLine(
+		"gcluster", "replicate", fmt.Sprintf("%d", slot.ID), cluster.self))
+	if protocol.IsErrorReply(ret) {
+		return fmt.Errorf("replicate slot %d to %s rejected: %v", slot.ID, node.ID, ret)
+	}
+	logger.Info(fmt.Sprintf("node %s is now a replica of slot %d", cluster.displayName(node.ID), slot.ID))
+	return nil
+}
+
+// StartFailureDetector launches the background loop that watches for dead primaries.
+// Only the raft leader acts on what it sees, same as StartSnapshotter's leader-only
+// compaction, so followers don't race each other over the same slot.
+//
+// todo: detectAndPromote only logs today; it stops short of calling PromoteReplica
+// automatically. replicateSlot has no keyspace iterator to hand a new replica a real
+// snapshot (cluster.db exposes no "dump this slot" API in this package), so a replica's
+// ReplicaNodeIDs membership is not a liveness/catch-up guarantee. Auto-promoting on that
+// basis would make a replica that never actually received the slot's data the new
+// primary and silently drop it - worse than no failover. Wire PromoteReplica back in here
+// once ensureReplicas can tell a caught-up replica from a newly-added, still-empty one.
+func StartFailureDetector(cluster *Cluster) {
+	ticker := time.NewTicker(failureDetectorInterval)
+	go func() {
+		for range ticker.C {
+			cluster.detectAndPromote()
+		}
+	}()
+}
+
+// detectAndPromote scans every slot with replicas for a primary that has gone silent
+// past failureTimeout() and reports it, leaving the actual promotion to an operator via
+// `CLUSTER PROMOTE-REPLICA` until a real catch-up signal exists (see StartFailureDetector)
+func (cluster *Cluster) detectAndPromote() {
+	nodeMap := cluster.topology.GetTopology()
+	self := nodeMap[cluster.self]
+	if self == nil || self.getState() != leader {
+		return
+	}
+	timeout := failureTimeout()
+	for _, node := range nodeMap {
+		if time.Since(node.lastHeard) < timeout {
+			continue
+		}
+		for _, slot := range node.Slots {
+			if len(slot.ReplicaNodeIDs) == 0 {
+				continue
+			}
+			logger.Error(fmt.Sprintf("primary %s of slot %d looks dead (last heard %s ago), run CLUSTER PROMOTE-REPLICA %d to fail over manually",
+				cluster.displayName(node.ID), slot.ID, time.Since(node.lastHeard), slot.ID))
+		}
+	}
+}
+
+// ExecReplicate is the receiving-side handler for the `gcluster replicate <slotId>
+// <primaryNodeId>` command replicateSlot sends: it applies the snapshot and write stream
+// the primary pushes over the same connection, executing each command as it arrives (no
+// batching, unlike importSlot), until the primary sends a terminal StatusReply.
+//
+// todo: the primary side (replicateSlot) only performs the handshake today; it has no
+// keyspace iterator to hand over (cluster.db exposes no "dump this slot" API in this
+// package), so until that's added this handler only ever sees whatever the primary
+// chooses to forward, not a guaranteed full snapshot.
+func (cluster *Cluster) ExecReplicate(conn net.Conn, args [][]byte) error {
+	if len(args) < 2 {
+		return fmt.Errorf("ERR wrong number of arguments for 'gcluster replicate' command")
+	}
+	fakeConn := connection.NewFakeConn()
+	for proto := range parser.ParseStream(conn) {
+		if proto.Err != nil {
+			return proto.Err
+		}
+		switch reply := proto.Data.(type) {
+		case *protocol.MultiBulkReply:
+			_ = cluster.db.Exec(fakeConn, reply.Args)
+		case *protocol.StatusReply:
+			if protocol.IsOKReply(reply) {
+				return nil
+			}
+			return fmt.Errorf("replicate stream error: %s", reply.ToBytes())
+		}
+	}
+	return nil
+}
+
+// PromoteReplica backs `CLUSTER PROMOTE-REPLICA <slot>`: it proposes making the slot's
+// first replica its new primary through raft, the same way SetAlias proposes an alias
+// through topology.setAlias rather than writing the topology maps directly, so every
+// node's view of the slot's ownership updates together instead of only the one that
+// happened to call this.
+func (cluster *Cluster) PromoteReplica(slotID uint32) error {
+	newPrimaryID, oldPrimaryID, err := cluster.topology.promoteReplica(slotID)
+	if err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("promoted replica %s to primary of slot %d (was %s)",
+		cluster.displayName(newPrimaryID), slotID, cluster.displayName(oldPrimaryID)))
+	return nil
+}
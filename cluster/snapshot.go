@@ -0,0 +1,167 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/hdt3213/godis/config"
+	"github.com/hdt3213/godis/lib/logger"
+)
+
+// defaultSnapshotInterval is the fallback wall-clock period between snapshot attempts
+const defaultSnapshotInterval = 5 * time.Minute
+
+// snapshotFileName is the file snapshots are written to under the node's data dir;
+// it is overwritten atomically so a crash never leaves a partially-written snapshot
+const snapshotFileName = "cluster-snapshot.json"
+
+// Snapshot is a point-in-time copy of the full cluster topology plus the raft position
+// it was taken at, used to let a joining or far-behind node catch up without replaying
+// the whole log
+type Snapshot struct {
+	LastIndex int
+	LastTerm  int
+	Nodes     map[string]*Node
+}
+
+// snapshotInterval returns the minimum wall-clock time between snapshot attempts
+func snapshotInterval() time.Duration {
+	if config.Properties.ClusterSnapshotIntervalSec <= 0 {
+		return defaultSnapshotInterval
+	}
+	return time.Duration(config.Properties.ClusterSnapshotIntervalSec) * time.Second
+}
+
+// StartSnapshotter runs the leader's periodic snapshot loop in the background. It keeps
+// ticking on every node but only takes a snapshot and saves it to disk when this node is
+// the raft leader; followers write no snapshots.
+func StartSnapshotter(cluster *Cluster, dataDir string) {
+	ticker := time.NewTicker(snapshotInterval())
+	go func() {
+		for range ticker.C {
+			cluster.maybeCompact(dataDir)
+		}
+	}()
+}
+
+// maybeCompact takes and persists a snapshot when this node is the raft leader. Cadence
+// is governed purely by snapshotInterval()'s ticker: no code in this package reads the
+// raft log's local commit position, so there is no entries-since-last-snapshot trigger.
+//
+// todo: LastIndex/LastTerm should be the raft log position the snapshot was taken at, so
+// a recovering node knows which log entries it can safely skip replaying. Neither is
+// exposed by this package today, so they are left at zero here and pushSnapshotToPeers is
+// deliberately not called: pushing a snapshot that isn't tied to a real commit position
+// to a peer over gRPC gives that peer no way to tell whether the snapshot is newer than
+// its own state, so InstallSnapshot could clobber a follower that's already further
+// ahead. Saving to local disk is still safe and useful on its own - it only ever feeds
+// back into this same node's own RecoverFromDisk - so that part stays wired up. Start
+// calling pushSnapshotToPeers from here once a real commit position is available.
+func (cluster *Cluster) maybeCompact(dataDir string) {
+	nodeMap := cluster.topology.GetTopology()
+	self := nodeMap[cluster.self]
+	if self == nil || self.getState() != leader {
+		return
+	}
+	snap := &Snapshot{Nodes: nodeMap}
+	if err := saveSnapshotToDisk(dataDir, snap); err != nil {
+		logger.Error(fmt.Sprintf("save cluster snapshot error: %v", err))
+		return
+	}
+	logger.Info("took cluster topology snapshot")
+}
+
+// saveSnapshotToDisk writes snap to dataDir/cluster-snapshot.json, replacing any
+// previous snapshot atomically via rename
+func saveSnapshotToDisk(dataDir string, snap *Snapshot) error {
+	bin, err := json.Marshal(snapshotOnDisk{
+		LastIndex: snap.LastIndex,
+		LastTerm:  snap.LastTerm,
+		Nodes:     marshalTopologyStrings(snap.Nodes),
+	})
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dataDir, snapshotFileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, bin, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadLatestSnapshotFromDisk reads dataDir/cluster-snapshot.json, returning (nil, nil)
+// when no snapshot has ever been taken on this node
+func loadLatestSnapshotFromDisk(dataDir string) (*Snapshot, error) {
+	path := filepath.Join(dataDir, snapshotFileName)
+	bin, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var onDisk snapshotOnDisk
+	if err := json.Unmarshal(bin, &onDisk); err != nil {
+		return nil, fmt.Errorf("corrupt cluster snapshot: %v", err)
+	}
+	nodes, err := unmarshalTopologyStrings(onDisk.Nodes)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{LastIndex: onDisk.LastIndex, LastTerm: onDisk.LastTerm, Nodes: nodes}, nil
+}
+
+// snapshotOnDisk is the JSON envelope persisted to disk; it stores node payloads as
+// strings since marshalTopology returns [][]byte sized for RESP framing, not JSON arrays
+type snapshotOnDisk struct {
+	LastIndex int      `json:"lastIndex"`
+	LastTerm  int      `json:"lastTerm"`
+	Nodes     []string `json:"nodes"`
+}
+
+func marshalTopologyStrings(nodes map[string]*Node) []string {
+	bins := marshalTopology(nodes)
+	result := make([]string, len(bins))
+	for i, bin := range bins {
+		result[i] = string(bin)
+	}
+	return result
+}
+
+func unmarshalTopologyStrings(lines []string) (map[string]*Node, error) {
+	bins := make([][]byte, len(lines))
+	for i, line := range lines {
+		bins[i] = []byte(line)
+	}
+	return unmarshalTopology(bins)
+}
+
+// RecoverFromDisk loads the newest on-disk snapshot (if any) into the local raft copy
+// before the caller replays whatever log entries were committed after it, so a restarted
+// node does not have to ask the leader for the entire topology history
+func (cluster *Cluster) RecoverFromDisk(dataDir string) error {
+	snap, err := loadLatestSnapshotFromDisk(dataDir)
+	if err != nil {
+		return err
+	}
+	if snap == nil {
+		return nil
+	}
+	cluster.installSnapshot(snap)
+	logger.Info("restored cluster topology from snapshot at index " + strconv.Itoa(snap.LastIndex))
+	return nil
+}
+
+// installSnapshot loads snap into the local raft copy, used both during crash recovery
+// and by the InstallSnapshot gRPC handler when a leader pushes a snapshot to this node.
+// The leader id is left blank: a node installing a snapshot doesn't necessarily know who
+// currently leads, and the normal raft heartbeat/election path (started separately via
+// start()) re-establishes it.
+func (cluster *Cluster) installSnapshot(snap *Snapshot) {
+	cluster.topology.Load(cluster.self, "", snap.LastTerm, snap.LastIndex, snap.Nodes)
+}
@@ -12,9 +12,9 @@ import (
 	"github.com/hdt3213/godis/redis/parser"
 	"github.com/hdt3213/godis/redis/protocol"
 	"hash/crc32"
-	"net"
 	"sort"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -25,6 +25,9 @@ type Node struct {
 	Slots     []*Slot // ascending order by slot id
 	Flags     uint32
 	lastHeard time.Time
+	// Alias is an optional, operator-assigned human-readable name for this node, unique
+	// across the cluster. Empty when the node has never been given one.
+	Alias string
 }
 
 const (
@@ -40,6 +43,13 @@ const (
 	learner
 )
 
+// topologyMu serializes this package's own reads-then-writes of the shared topology
+// maps (raft.nodes/raft.slots) around FinishSlotMigrate, setLocalSlotMigrating and
+// addSlotReplica. rebalance runs importSlot/ensureReplicas across up to
+// maxParallelMigrations() goroutines, and two slots migrating away from the same source
+// node both end up mutating that node's Slots slice; without this lock they can race.
+var topologyMu sync.Mutex
+
 func (node *Node) setState(state raftState) {
 	node.Flags &= ^uint32(0x7) // clean
 	switch state {
@@ -83,14 +93,33 @@ type Slot struct {
 	OldNodeID string
 	// Flags stores more information of slot
 	Flags uint32
+	// ReplicaNodeIDs holds the ids of nodes that accepted the replicate handshake for this
+	// slot (see replicateSlot), ordered by the time they joined the replica set; being
+	// listed here does not mean a node holds a caught-up copy of the slot's data
+	ReplicaNodeIDs []string
+	// MigrationCursor counts the migration commands (not keys) importSlot has already
+	// applied while this slot is migrating, and importSlot resumes from it when non-zero.
+	// It travels in the marshalled topology and on-disk snapshots; importSlot updates it
+	// on the local copy only and does not propose it through the raft log.
+	MigrationCursor int
 }
 
 func (slot *Slot) IsMigrating() bool {
 	return slot.Flags&slotFlagMigrating > 0
 }
 
+// HasReplica returns whether nodeID is already keeping a replica of this slot
+func (slot *Slot) HasReplica(nodeID string) bool {
+	for _, id := range slot.ReplicaNodeIDs {
+		if id == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
 func getSlot(key string) uint32 {
-	return crc32.ChecksumIEEE([]byte(key)) % uint32(slotCount)
+	return crc32.ChecksumIEEE([]byte(hashTagOf(key))) % uint32(slotCount)
 }
 
 func (cluster *Cluster) startAsSeed() error {
@@ -105,6 +134,25 @@ func (cluster *Cluster) startAsSeed() error {
 	return nil
 }
 
+// ensureReplicas tops up slot's replica set up to replicationFactor() with distinct nodes
+// other than the slot's own primary, and starts the replicate handshake for newly picked ones
+func (cluster *Cluster) ensureReplicas(slot *Slot) {
+	n := replicationFactor() - len(slot.ReplicaNodeIDs)
+	if n <= 0 {
+		return
+	}
+	candidates := cluster.pickReplicaCandidates(slot, n)
+	for _, node := range candidates {
+		if err := cluster.replicateSlot(node, slot); err != nil {
+			logger.Error(fmt.Sprintf("replicate slot %d to %s error: %v", slot.ID, cluster.displayName(node.ID), err))
+			continue
+		}
+		topologyMu.Lock()
+		cluster.topology.addSlotReplica(slot.ID, node.ID)
+		topologyMu.Unlock()
+	}
+}
+
 // findSlotsForNewNode try to find slots for new node, but do not actually migrate
 func (cluster *Cluster) findSlotsForNewNode() []*Slot {
 	nodeMap := cluster.topology.GetTopology() // including the new node
@@ -139,14 +187,19 @@ func (cluster *Cluster) findSlotsForNewNode() []*Slot {
 	return result
 }
 
-// Join send `gcluster join` to node in cluster to join
+// Join asks seed for the leader and sends it `raft join` to join. seed is either a raw address
+// or an `alias@address` pair; when an alias is given, it is assigned to this node once
+// the join completes.
 func (cluster *Cluster) Join(seed string) protocol.ErrorReply {
-	seedCli, err := client.MakeClient(seed)
+	selfAlias, seedAddr := splitAliasAddr(seed)
+	seedCli, err := client.MakeClient(seedAddr)
 	if err != nil {
 		return protocol.MakeErrReply("connect with seed failed: " + err.Error())
 	}
 	seedCli.Start()
-	// todo: auth
+	if authErr := authenticate(seedCli); authErr != nil {
+		return authErr
+	}
 	ret := seedCli.Send(utils.ToCmdLine("raft", "get-leader"))
 	if protocol.IsErrorReply(ret) {
 		return ret.(protocol.ErrorReply)
@@ -156,32 +209,49 @@ func (cluster *Cluster) Join(seed string) protocol.ErrorReply {
 		return protocol.MakeErrReply("ERR get-leader returns wrong reply")
 	}
 	leaderAddr := string(leaderInfo.Args[1])
-	leaderCli, err := client.MakeClient(leaderAddr)
-	// todo: auth
-	if err != nil {
-		return protocol.MakeErrReply("connect with seed failed: " + err.Error())
-	}
-	leaderCli.Start()
-	ret = leaderCli.Send(utils.ToCmdLine("raft", "join", config.Properties.AnnounceAddress()))
-	// todo: handle NOT LEADER error
-	if protocol.IsErrorReply(ret) {
-		return ret.(protocol.ErrorReply)
-	}
-	topology, ok := ret.(*protocol.MultiBulkReply)
-	if !ok || len(topology.Args) < 4 {
-		return protocol.MakeErrReply("ERR gcluster join returns wrong reply")
-	}
-	selfNodeId := string(topology.Args[0])
-	leaderId := string(topology.Args[1])
-	term, _ := strconv.Atoi(string(topology.Args[2]))
-	commitIndex, _ := strconv.Atoi(string(topology.Args[3]))
-	nodes, err := unmarshalTopology(topology.Args[4:])
-	if err != nil {
-		return protocol.MakeErrReply(err.Error())
+	var selfNodeId, leaderId string
+	var term, commitIndex int
+	var nodes map[string]*Node
+	if grpcEnabled() {
+		selfNodeId, leaderId, term, commitIndex, nodes, err = cluster.joinViaGRPC(leaderAddr)
+		if err != nil {
+			return protocol.MakeErrReply(err.Error())
+		}
+	} else {
+		leaderCli, err := client.MakeClient(leaderAddr)
+		if err != nil {
+			return protocol.MakeErrReply("connect with seed failed: " + err.Error())
+		}
+		leaderCli.Start()
+		if authErr := authenticate(leaderCli); authErr != nil {
+			return authErr
+		}
+		ret = leaderCli.Send(utils.ToCmdLine("raft", "join", config.Properties.AnnounceAddress()))
+		// todo: handle NOT LEADER error
+		if protocol.IsErrorReply(ret) {
+			return ret.(protocol.ErrorReply)
+		}
+		topology, ok := ret.(*protocol.MultiBulkReply)
+		if !ok || len(topology.Args) < 4 {
+			return protocol.MakeErrReply("ERR gcluster join returns wrong reply")
+		}
+		selfNodeId = string(topology.Args[0])
+		leaderId = string(topology.Args[1])
+		term, _ = strconv.Atoi(string(topology.Args[2]))
+		commitIndex, _ = strconv.Atoi(string(topology.Args[3]))
+		nodes, err = unmarshalTopology(topology.Args[4:])
+		if err != nil {
+			return protocol.MakeErrReply(err.Error())
+		}
 	}
 	cluster.topology.Load(selfNodeId, leaderId, term, commitIndex, nodes)
 	cluster.self = selfNodeId
 	cluster.topology.start(follower)
+	if selfAlias != "" {
+		if aliasErr := cluster.SetAlias(cluster.self, selfAlias); aliasErr != nil {
+			logger.Error(fmt.Sprintf("set alias %s error: %v", selfAlias, aliasErr))
+		}
+	}
 	// asynchronous migrating slots
 	go func() {
 		time.Sleep(time.Second) // let the cluster started
@@ -190,32 +260,46 @@ func (cluster *Cluster) Join(seed string) protocol.ErrorReply {
 	return nil
 }
 
+// rebalance migrates the slots findSlotsForNewNode picked, running up to
+// maxParallelMigrations() of them concurrently so a large rebalance does not take
+// forever, while still bounding load on the cluster
 func (cluster *Cluster) rebalance(err error) {
 	slots := cluster.findSlotsForNewNode()
-	// serial migrations to avoid overloading the cluster
+	sem := make(chan struct{}, maxParallelMigrations())
+	var wg sync.WaitGroup
 	for _, slot := range slots {
 		if slot.IsMigrating() {
 			continue
 		}
-		logger.Info("start import slot ", slot.ID)
-		err = cluster.importSlot(slot)
-		if err != nil {
-			logger.Error(fmt.Sprintf("import slot %d error: %d", slot.ID, err))
-			// todo: delete all keys in slot
-			continue
-		}
-		logger.Info("finish import slot", slot.ID)
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(slot *Slot) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			logger.Info("start import slot ", slot.ID)
+			if err := cluster.importSlot(slot); err != nil {
+				logger.Error(fmt.Sprintf("import slot %d error: %v", slot.ID, err))
+				// todo: delete all keys in slot
+				return
+			}
+			logger.Info("finish import slot", slot.ID)
+			cluster.ensureReplicas(slot)
+		}(slot)
 	}
+	wg.Wait()
 }
 
+// importSlot migrates slot from its current owner to cluster.self, resuming from
+// slot.MigrationCursor when the slot was already mid-migration (e.g. after a restart)
 func (cluster *Cluster) importSlot(slot *Slot) error {
 	fakeConn := connection.NewFakeConn()
 	node := cluster.topology.PickNode(slot.ID)
-	conn, err := net.Dial("tcp", node.Addr)
+	conn, err := dialNode(node)
 	if err != nil {
 		return fmt.Errorf("connect with %s(%s) error: %v", node.ID, node.Addr, err)
 	}
 	nodeChan := parser.ParseStream(conn)
+	limiter := newRateLimiter(migrationRateLimitBytesPerSec())
 	send2node := func(cmdLine CmdLine) redis.Reply {
 		req := protocol.MakeMultiBulkReply(cmdLine)
 		_, err := conn.Write(req.ToBytes())
@@ -228,11 +312,26 @@ func (cluster *Cluster) importSlot(slot *Slot) error {
 		}
 		return resp.Data
 	}
+	if token := clusterToken(); token != "" {
+		if ret := send2node(utils.ToCmdLine("gcluster", "auth", token)); protocol.IsErrorReply(ret) {
+			return fmt.Errorf("auth with %s error: %v", cluster.displayName(node.ID), ret)
+		}
+	}
 
+	cursor := slot.MigrationCursor
 	cluster.setSlot(slot.ID, slotStateImporting) // prepare host slot before send `set slot`
+	topologyMu.Lock()
 	cluster.topology.setLocalSlotMigrating(slot.ID, cluster.self)
-	ret := send2node(utils.ToCmdLine(
-		"gcluster", "set-slot", strconv.Itoa(int(slot.ID)), cluster.self))
+	topologyMu.Unlock()
+	var ret redis.Reply
+	if cursor > 0 {
+		// the slot was already migrating before a restart, skip what was already transferred
+		ret = send2node(utils.ToCmdLine(
+			"gcluster", "migrate-resume", strconv.Itoa(int(slot.ID)), strconv.Itoa(cursor)))
+	} else {
+		ret = send2node(utils.ToCmdLine(
+			"gcluster", "set-slot", strconv.Itoa(int(slot.ID)), cluster.self))
+	}
 	if !protocol.IsOKReply(ret) {
 		return fmt.Errorf("set slot %d error: %v", slot.ID, err)
 	}
@@ -244,6 +343,32 @@ func (cluster *Cluster) importSlot(slot *Slot) error {
 	if err != nil {
 		return protocol.MakeErrReply(err.Error())
 	}
+	// resumeSkip re-applies resume locally rather than trusting the source to seek: this
+	// package has no exported accessor into the source's keyspace iterator (cluster.db
+	// exposes no "resume this slot dump from key N" API), so `gcluster migrate-resume`
+	// may simply replay the slot from the beginning. Skipping the first `cursor` entries
+	// here is correct when the source replays from the beginning; a source that itself
+	// skips the first `cursor` entries would have them skipped twice.
+	resumeSkip := cursor
+	batch := make([]*protocol.MultiBulkReply, 0, migrationBatchSize)
+	flush := func() {
+		for _, reply := range batch {
+			if resumeSkip > 0 {
+				resumeSkip--
+			} else {
+				// todo: handle exec error
+				_ = cluster.db.Exec(fakeConn, reply.Args)
+				keys, _ := database.GetRelatedKeys(reply.Args)
+				for _, key := range keys {
+					cluster.setImportedKey(key)
+				}
+			}
+			cursor++
+			limiter.wait(reply.ToBytes())
+		}
+		slot.MigrationCursor = cursor
+		batch = batch[:0]
+	}
 slotLoop:
 	for proto := range nodeChan {
 		if proto.Err != nil {
@@ -251,13 +376,12 @@ slotLoop:
 		}
 		switch reply := proto.Data.(type) {
 		case *protocol.MultiBulkReply:
-			// todo: handle exec error
-			_ = cluster.db.Exec(fakeConn, reply.Args)
-			keys, _ := database.GetRelatedKeys(reply.Args)
-			for _, key := range keys {
-				cluster.setImportedKey(key)
+			batch = append(batch, reply)
+			if len(batch) >= migrationBatchSize {
+				flush()
 			}
 		case *protocol.StatusReply:
+			flush()
 			if protocol.IsOKReply(reply) {
 				break slotLoop
 			}
@@ -265,12 +389,20 @@ slotLoop:
 	}
 	cluster.slots[slot.ID].importedKeys = nil
 	cluster.slots[slot.ID].state = slotStateHost
+	slot.MigrationCursor = 0
 	cluster.FinishSlotMigrate(slot.ID)
 	send2node(utils.ToCmdLine("gcluster", "migrate-done", strconv.Itoa(int(slot.ID))))
 	return nil
 }
 
+// FinishSlotMigrate moves slotID from its old owner to its new one in the local raft
+// copy once importSlot has transferred every key. rebalance runs this from up to
+// maxParallelMigrations() goroutines at once, and two slots finishing migration away
+// from the same source node both append/slice-copy that node's Slots, so topologyMu
+// guards every call here the same way it guards setLocalSlotMigrating/addSlotReplica.
 func (cluster *Cluster) FinishSlotMigrate(slotID uint32) {
+	topologyMu.Lock()
+	defer topologyMu.Unlock()
 	// todo: raft 不再关注迁移状态信息, 只关心由谁负责 slot
 	raft := cluster.topology
 	slot := raft.slots[int(slotID)]
@@ -288,4 +420,16 @@ func (cluster *Cluster) FinishSlotMigrate(slotID uint32) {
 	newNode.Slots = append(newNode.Slots, slot)
 	slot.Flags &= ^slotFlagMigrating
 	slot.OldNodeID = ""
+}
+
+// forgetNode evicts nodeID from the raft-replicated topology, for CLUSTER FORGET to let
+// an operator remove a node that will never come back (e.g. destroyed hardware) without
+// going through the normal rebalance-away-its-slots flow. It proposes the eviction through
+// topology.forgetNode, the same raft-apply pattern topology.setAlias uses, instead of
+// deleting from raft.nodes directly, so every node's view of membership updates together.
+func (cluster *Cluster) forgetNode(nodeID string) protocol.ErrorReply {
+	if err := cluster.topology.forgetNode(nodeID); err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	return nil
 }
\ No newline at end of file
@@ -81,22 +81,51 @@ func unmarshalSlotIds(args []string) ([]uint32, error) {
 	return result, nil
 }
 
+// migratingSlotPayload carries the per-slot fields that the compact range encoding in
+// SlotDesc can't express (it only has room for a bare slot id): a slot only gets an entry
+// here when it is mid-migration or has replicas, so the common case (a slot fully owned,
+// at rest, with no replicas) costs nothing beyond its id in SlotDesc.
+type migratingSlotPayload struct {
+	ID              uint32   `json:"id"`
+	OldNodeID       string   `json:"oldNodeId,omitempty"`
+	Flags           uint32   `json:"flags,omitempty"`
+	MigrationCursor int      `json:"migrationCursor,omitempty"`
+	ReplicaNodeIDs  []string `json:"replicaNodeIds,omitempty"`
+}
+
 type nodePayload struct {
-	ID       string   `json:"id"`
-	Addr     string   `json:"addr"`
-	SlotDesc []string `json:"slotDesc"`
-	Flags    uint32   `json:"flags"`
+	ID             string                 `json:"id"`
+	Addr           string                 `json:"addr"`
+	SlotDesc       []string               `json:"slotDesc"`
+	Flags          uint32                 `json:"flags"`
+	Alias          string                 `json:"alias,omitempty"`
+	MigratingSlots []migratingSlotPayload `json:"migratingSlots,omitempty"`
 }
 
 func marshalTopology(nodes map[string]*Node) [][]byte {
 	var args [][]byte
 	for _, node := range nodes {
 		slotLines := marshalSlotIds(node.Slots)
+		var migrating []migratingSlotPayload
+		for _, slot := range node.Slots {
+			if slot.OldNodeID == "" && slot.Flags == 0 && slot.MigrationCursor == 0 && len(slot.ReplicaNodeIDs) == 0 {
+				continue
+			}
+			migrating = append(migrating, migratingSlotPayload{
+				ID:              slot.ID,
+				OldNodeID:       slot.OldNodeID,
+				Flags:           slot.Flags,
+				MigrationCursor: slot.MigrationCursor,
+				ReplicaNodeIDs:  slot.ReplicaNodeIDs,
+			})
+		}
 		payload := &nodePayload{
-			ID:       node.ID,
-			Addr:     node.Addr,
-			SlotDesc: slotLines,
-			Flags:    node.Flags,
+			ID:             node.ID,
+			Addr:           node.Addr,
+			SlotDesc:       slotLines,
+			Flags:          node.Flags,
+			Alias:          node.Alias,
+			MigratingSlots: migrating,
 		}
 		bin, _ := json.Marshal(payload)
 		args = append(args, bin)
@@ -120,13 +149,25 @@ func unmarshalTopology(args [][]byte) (map[string]*Node, error) {
 			ID:    payload.ID,
 			Addr:  payload.Addr,
 			Flags: payload.Flags,
+			Alias: payload.Alias,
+		}
+		extra := make(map[uint32]migratingSlotPayload, len(payload.MigratingSlots))
+		for _, m := range payload.MigratingSlots {
+			extra[m.ID] = m
 		}
 		for _, slotId := range slotIds {
-			node.Slots = append(node.Slots, &Slot{
+			slot := &Slot{
 				ID:     slotId,
 				NodeID: node.ID,
 				Flags:  0,
-			})
+			}
+			if m, ok := extra[slotId]; ok {
+				slot.OldNodeID = m.OldNodeID
+				slot.Flags = m.Flags
+				slot.MigrationCursor = m.MigrationCursor
+				slot.ReplicaNodeIDs = m.ReplicaNodeIDs
+			}
+			node.Slots = append(node.Slots, slot)
 		}
 		nodeMap[node.ID] = node
 	}
@@ -0,0 +1,66 @@
+package config
+
+import "strconv"
+
+// ServerProperties holds every setting godis reads from its config file/CLI flags.
+// Only the fields the cluster package depends on are declared here; Properties is
+// populated by the server's startup config loader before any cluster code runs.
+type ServerProperties struct {
+	Bind string `cfg:"bind"`
+	Port int    `cfg:"port"`
+
+	// AnnounceHost/AnnouncePort, when set, are what this node tells peers to dial
+	// instead of Bind/Port, for deployments where the listen address isn't reachable
+	// from other nodes (e.g. behind NAT or a container port mapping)
+	AnnounceHost string `cfg:"announce-host"`
+	AnnouncePort int     `cfg:"announce-port"`
+
+	// ClusterReplicationFactor is how many replica nodes should be kept per slot;
+	// <= 0 falls back to defaultReplicationFactor
+	ClusterReplicationFactor int `cfg:"cluster-replication-factor"`
+	// ClusterFailureTimeoutMs is how long a primary may go unheard from before the
+	// failure detector considers it dead; <= 0 falls back to defaultFailureTimeout
+	ClusterFailureTimeoutMs int `cfg:"cluster-failure-timeout-ms"`
+
+	// ClusterMaxParallelMigrations bounds how many slots rebalance migrates at the
+	// same time; <= 0 falls back to defaultMaxParallelMigrations
+	ClusterMaxParallelMigrations int `cfg:"cluster-max-parallel-migrations"`
+	// ClusterMigrationRateLimitBytesPerSec throttles per-slot migration traffic;
+	// 0 means unlimited
+	ClusterMigrationRateLimitBytesPerSec int `cfg:"cluster-migration-rate-limit-bytes-per-sec"`
+
+	// ClusterGRPCEnabled switches inter-node cluster traffic from the default RESP
+	// transport to gRPC
+	ClusterGRPCEnabled bool `cfg:"cluster-grpc-enabled"`
+
+	// ClusterAuthToken is the shared secret every node must present on `raft join` and
+	// on every `gcluster` command; empty disables the check
+	ClusterAuthToken string `cfg:"cluster-auth-token"`
+	// ClusterTLSCert/ClusterTLSKey/ClusterTLSCA locate the mutual TLS material for
+	// inter-node traffic; TLS is disabled unless all three are set
+	ClusterTLSCert string `cfg:"cluster-tls-cert"`
+	ClusterTLSKey  string `cfg:"cluster-tls-key"`
+	ClusterTLSCA   string `cfg:"cluster-tls-ca"`
+
+	// ClusterSnapshotIntervalSec is the minimum wall-clock time between topology
+	// snapshot attempts; <= 0 falls back to defaultSnapshotInterval
+	ClusterSnapshotIntervalSec int `cfg:"cluster-snapshot-interval-sec"`
+}
+
+// Properties is the process-wide, already-loaded configuration; it is populated by
+// the server's startup config loader before any cluster code runs.
+var Properties *ServerProperties
+
+// AnnounceAddress returns the address this node advertises to peers: AnnounceHost/
+// AnnouncePort when set, otherwise Bind/Port
+func (p *ServerProperties) AnnounceAddress() string {
+	host := p.AnnounceHost
+	if host == "" {
+		host = p.Bind
+	}
+	port := p.AnnouncePort
+	if port == 0 {
+		port = p.Port
+	}
+	return host + ":" + strconv.Itoa(port)
+}